@@ -2,7 +2,9 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -28,9 +30,6 @@ type Point struct {
 	y int
 }
 
-// Path represents a path taken
-type Path []Point
-
 // DistanceTo finds the manhattan distance between p and p2
 func (p Point) DistanceTo(p2 Point) int {
 	return abs(p.x-p2.x) + abs(p.y-p2.y)
@@ -44,33 +43,329 @@ func (p Point) Add(p2 Point) Point {
 	}
 }
 
-// Intersection finds the intersection between two paths
+// Segment represents a single axis-aligned segment of a Path, running from
+// start to end, along with the cumulative number of steps the wire has
+// already taken by the time it reaches start.
+type Segment struct {
+	start        Point
+	end          Point
+	stepsToStart int
+}
+
+// length returns the number of steps it takes to walk the segment.
+func (s Segment) length() int {
+	return s.start.DistanceTo(s.end)
+}
+
+// isVertical returns whether the segment runs along the y axis.
+func (s Segment) isVertical() bool {
+	return s.start.x == s.end.x
+}
+
+// contains reports whether point lies somewhere on the segment.
+func (s Segment) contains(point Point) bool {
+	if s.isVertical() {
+		return point.x == s.start.x && between(point.y, s.start.y, s.end.y)
+	}
+
+	return point.y == s.start.y && between(point.x, s.start.x, s.end.x)
+}
+
+// Path represents a path taken, stored as a sequence of axis-aligned
+// segments rather than the individual grid points it passes through. Its
+// step map and fingerprint are computed lazily and cached on first use.
+type Path struct {
+	segments        []Segment
+	stepMap         map[Point]int
+	fingerprint     uint64
+	haveFingerprint bool
+}
+
+// ccw computes twice the signed area of the triangle p, q, r. Its sign
+// indicates the orientation of r relative to the directed line p->q: 0 means
+// the three points are colinear.
+func ccw(p, q, r Point) int {
+	return (q.x-p.x)*(r.y-p.y) - (q.y-p.y)*(r.x-p.x)
+}
+
+// segmentsIntersect finds every point at which s1 and s2 overlap. Two
+// non-colinear segments cross in at most one point, found via the standard
+// counterclockwise orientation test: comparing the sign of ccw, not its raw
+// value, is what actually tells you which side of the line a point falls
+// on. Because both segments are axis-aligned, that crossing point is just
+// the shared x coordinate of the vertical segment and the shared y
+// coordinate of the horizontal one. Same-orientation segments can instead
+// overlap along an entire shared run, so every point in that run is
+// returned.
+func segmentsIntersect(s1, s2 Segment) []Point {
+	a, b := s1.start, s1.end
+	c, d := s2.start, s2.end
+
+	cSide, dSide := sign(ccw(a, b, c)), sign(ccw(a, b, d))
+	aSide, bSide := sign(ccw(c, d, a)), sign(ccw(c, d, b))
+
+	if cSide != dSide && aSide != bSide {
+		vertical, horizontal := s1, s2
+		if !vertical.isVertical() {
+			vertical, horizontal = horizontal, vertical
+		}
+
+		return []Point{{x: vertical.start.x, y: horizontal.start.y}}
+	}
+
+	if s1.isVertical() == s2.isVertical() {
+		return colinearOverlap(s1, s2)
+	}
+
+	return nil
+}
+
+// colinearOverlap handles the case where s1 and s2 share an orientation
+// (both vertical or both horizontal), returning every point along their
+// shared axis that both segments cover.
+func colinearOverlap(s1, s2 Segment) []Point {
+	if s1.isVertical() {
+		if s1.start.x != s2.start.x {
+			return nil
+		}
+
+		lo, hi, ok := rangeOverlap(s1.start.y, s1.end.y, s2.start.y, s2.end.y)
+		if !ok {
+			return nil
+		}
+
+		points := make([]Point, 0, hi-lo+1)
+		for y := lo; y <= hi; y++ {
+			points = append(points, Point{x: s1.start.x, y: y})
+		}
+
+		return points
+	}
+
+	if s1.start.y != s2.start.y {
+		return nil
+	}
+
+	lo, hi, ok := rangeOverlap(s1.start.x, s1.end.x, s2.start.x, s2.end.x)
+	if !ok {
+		return nil
+	}
+
+	points := make([]Point, 0, hi-lo+1)
+	for x := lo; x <= hi; x++ {
+		points = append(points, Point{x: x, y: s1.start.y})
+	}
+
+	return points
+}
+
+// rangeOverlap returns the bounds of the overlap between [a1,a2] and
+// [b1,b2], if one exists.
+func rangeOverlap(a1, a2, b1, b2 int) (int, int, bool) {
+	lo := max(min(a1, a2), min(b1, b2))
+	hi := min(max(a1, a2), max(b1, b2))
+	if lo > hi {
+		return 0, 0, false
+	}
+
+	return lo, hi, true
+}
+
+// between reports whether v falls within the (inclusive) range bounded by a
+// and b, regardless of which of the two is larger.
+func between(v, a, b int) bool {
+	return v >= min(a, b) && v <= max(a, b)
+}
+
+// Intersection finds the points at which path and path2 cross by testing
+// every segment of path against every segment of path2. For large inputs,
+// prefer building a PathIndex over one of the paths and calling
+// PathIndex.Intersections instead, which avoids the full O(N*M) comparison.
 func (path Path) Intersection(path2 Path) []Point {
 	res := []Point{}
-	pathPointSet := make(map[Point]struct{})
-	// Add all elements from the first path into the path set
-	for _, point := range path {
-		pathPointSet[point] = struct{}{}
+	seen := make(map[Point]struct{})
+	for _, s1 := range path.segments {
+		for _, s2 := range path2.segments {
+			for _, point := range segmentsIntersect(s1, s2) {
+				if _, ok := seen[point]; ok {
+					continue
+				}
+
+				seen[point] = struct{}{}
+				res = append(res, point)
+			}
+		}
 	}
 
-	for _, point := range path2 {
-		if _, ok := pathPointSet[point]; ok {
-			res = append(res, point)
+	return res
+}
+
+// bucketSize is the side length, in grid units, of each cell used to bucket
+// segments in a PathIndex.
+const bucketSize = 256
+
+// cell identifies a single bucket in the coarse grid a PathIndex buckets
+// segments into.
+type cell struct {
+	x int
+	y int
+}
+
+// PathIndex buckets a Path's segments by the coarse grid cells their
+// bounding boxes touch, so queries only compare segment pairs sharing a
+// cell instead of every segment against every other segment.
+type PathIndex struct {
+	path    Path
+	buckets map[cell][]int
+}
+
+// NewPathIndex builds a PathIndex over path.
+func NewPathIndex(path Path) PathIndex {
+	index := PathIndex{
+		path:    path,
+		buckets: make(map[cell][]int),
+	}
+
+	for i, segment := range path.segments {
+		for _, c := range cellsForSegment(segment) {
+			index.buckets[c] = append(index.buckets[c], i)
+		}
+	}
+
+	return index
+}
+
+// cellsForSegment returns every bucket cell that segment's bounding box
+// touches.
+func cellsForSegment(s Segment) []cell {
+	minX, minY := min(s.start.x, s.end.x), min(s.start.y, s.end.y)
+	maxX, maxY := max(s.start.x, s.end.x), max(s.start.y, s.end.y)
+
+	cells := []cell{}
+	for cx := floorDiv(minX, bucketSize); cx <= floorDiv(maxX, bucketSize); cx++ {
+		for cy := floorDiv(minY, bucketSize); cy <= floorDiv(maxY, bucketSize); cy++ {
+			cells = append(cells, cell{x: cx, y: cy})
+		}
+	}
+
+	return cells
+}
+
+// floorDiv divides a by b, rounding towards negative infinity, so that
+// negative coordinates still bucket contiguously with their positive
+// counterparts.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+
+	return q
+}
+
+// Intersections finds the points at which index's underlying path crosses
+// other, only comparing segment pairs that share a bucket.
+func (index PathIndex) Intersections(other Path) []Point {
+	res := []Point{}
+	seen := make(map[Point]struct{})
+	tested := make(map[[2]int]struct{})
+
+	for j, s2 := range other.segments {
+		for _, c := range cellsForSegment(s2) {
+			for _, i := range index.buckets[c] {
+				pairKey := [2]int{i, j}
+				if _, ok := tested[pairKey]; ok {
+					continue
+				}
+				tested[pairKey] = struct{}{}
+
+				for _, point := range segmentsIntersect(index.path.segments[i], s2) {
+					if _, ok := seen[point]; ok {
+						continue
+					}
+
+					seen[point] = struct{}{}
+					res = append(res, point)
+				}
+			}
 		}
 	}
 
 	return res
 }
 
-// Index returns the index at which the point occurs in the given path
-func (path Path) Index(point Point) (int, error) {
-	for i, pathPoint := range path {
-		if point == pathPoint {
-			return i, nil
+// Contains reports whether point lies somewhere on path.
+func (path Path) Contains(point Point) bool {
+	for _, segment := range path.segments {
+		if segment.contains(point) {
+			return true
 		}
 	}
 
-	return -1, errors.New("point not in path")
+	return false
+}
+
+// sign returns -1, 0, or 1 according to the sign of n.
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// walkPoints calls visit for every point the wire passes through, in order,
+// along with the cumulative step count at which it first reaches that
+// point. The origin is visited first, with a step count of 0.
+func (path Path) walkPoints(visit func(point Point, steps int)) {
+	visit(Point{0, 0}, 0)
+	for _, segment := range path.segments {
+		dx, dy := sign(segment.end.x-segment.start.x), sign(segment.end.y-segment.start.y)
+		cursor := segment.start
+		steps := segment.stepsToStart
+		for cursor != segment.end {
+			cursor = Point{x: cursor.x + dx, y: cursor.y + dy}
+			steps++
+			visit(cursor, steps)
+		}
+	}
+}
+
+// StepMap returns a map from every point the wire visits to the earliest
+// step count at which it reaches that point.
+func (path *Path) StepMap() map[Point]int {
+	if path.stepMap != nil {
+		return path.stepMap
+	}
+
+	stepMap := make(map[Point]int)
+	path.walkPoints(func(point Point, steps int) {
+		if _, ok := stepMap[point]; !ok {
+			stepMap[point] = steps
+		}
+	})
+
+	path.stepMap = stepMap
+	return path.stepMap
+}
+
+// Fingerprint returns a hash of the ordered points the wire visits.
+func (path *Path) Fingerprint() uint64 {
+	if path.haveFingerprint {
+		return path.fingerprint
+	}
+
+	h := fnv.New64a()
+	path.walkPoints(func(point Point, steps int) {
+		fmt.Fprintf(h, "%d,%d;", point.x, point.y)
+	})
+
+	path.fingerprint = h.Sum64()
+	path.haveFingerprint = true
+	return path.fingerprint
 }
 
 func abs(n int) int {
@@ -122,50 +417,47 @@ func makeDeltaPoint(pathComponent string) (Point, error) {
 // NewPathFromPathString makes a Path from a string representing it
 func NewPathFromPathString(rawPath string) (Path, error) {
 	cursor := Point{0, 0}
+	steps := 0
 	pathComponents := strings.Split(rawPath, ",")
-	path := Path{}
+	segments := make([]Segment, 0, len(pathComponents))
 	for _, component := range pathComponents {
 		deltaPoint, err := makeDeltaPoint(component)
 		if err != nil {
-			return nil, fmt.Errorf("Could not make delta point: %s", err)
+			return Path{}, fmt.Errorf("Could not make delta point: %s", err)
 		}
 
 		newCursor := cursor.Add(deltaPoint)
-		// Trace out the paths
-		// This is a bit gross, but I don't know of a better way to do it
-		if cursor.x < newCursor.x {
-			for i := cursor.x; i < newCursor.x; i++ {
-				path = append(path, Point{x: i, y: cursor.y})
-			}
-		} else {
-			for i := cursor.x; i > newCursor.x; i-- {
-				path = append(path, Point{x: i, y: cursor.y})
-			}
-		}
-
-		if cursor.y < newCursor.y {
-			for i := cursor.y; i < newCursor.y; i++ {
-				path = append(path, Point{x: cursor.x, y: i})
-			}
-		} else {
-			for i := cursor.y; i > newCursor.y; i-- {
-				path = append(path, Point{x: cursor.x, y: i})
-			}
-		}
+		segment := Segment{start: cursor, end: newCursor, stepsToStart: steps}
+		segments = append(segments, segment)
 
+		steps += segment.length()
 		cursor = newCursor
 	}
 
-	return path, nil
+	return Path{segments: segments}, nil
 }
 
-func part1(paths []Path) (int, error) {
-	intersections := paths[0]
-	// Get all points that intersect between the paths
-	for _, path := range paths[1:] {
-		intersections = intersections.Intersection(path)
+// intersectAll finds every point at which all of paths cross. The first two
+// paths are compared via a PathIndex, and any further paths are intersected
+// by filtering that result down to points they also contain, so adding a
+// third or fourth wire scales linearly rather than quadratically.
+func intersectAll(paths []Path) []Point {
+	points := NewPathIndex(paths[0]).Intersections(paths[1])
+	for _, path := range paths[2:] {
+		kept := points[:0]
+		for _, point := range points {
+			if path.Contains(point) {
+				kept = append(kept, point)
+			}
+		}
+
+		points = kept
 	}
 
+	return points
+}
+
+func part1(paths []Path, intersections []Point) (int, error) {
 	// this bitshift represents the max int on the system
 	// https://stackoverflow.com/a/6878625
 	minDistance := int(^uint(0) >> 1)
@@ -184,15 +476,12 @@ func part1(paths []Path) (int, error) {
 	return minDistance, nil
 }
 
-func part2(paths []Path) (int, error) {
-	intersections := paths[0]
-	// Get all points that intersect between the paths
-	for _, path := range paths[1:] {
-		intersections = intersections.Intersection(path)
+func part2(paths []Path, intersections []Point) (int, error) {
+	stepMaps := make([]map[Point]int, len(paths))
+	for i := range paths {
+		stepMaps[i] = paths[i].StepMap()
 	}
 
-	// this bitshift represents the max int on the system
-	// https://stackoverflow.com/a/6878625
 	minLength := int(^uint(0) >> 2)
 	for _, point := range intersections {
 		// We don't care about 0,0 as an intersection, as every path starts there.
@@ -201,14 +490,13 @@ func part2(paths []Path) (int, error) {
 		}
 
 		length := 0
-		for _, path := range paths {
-			// Index will equal the path length, as 0,0 is the first item in all paths
-			index, err := path.Index(point)
-			if err != nil {
-				return -1, fmt.Errorf("could not get index of point in path: %s", err)
+		for _, stepMap := range stepMaps {
+			steps, ok := stepMap[point]
+			if !ok {
+				return -1, errors.New("intersection point not in path's step map")
 			}
 
-			length += index
+			length += steps
 		}
 
 		if length < minLength {
@@ -220,6 +508,13 @@ func part2(paths []Path) (int, error) {
 }
 
 func main() {
+	debugImagePath := flag.String(
+		"debug-image",
+		"",
+		"optional path to write a visualization of the wire paths and their intersections to (.svg for vector output, anything else for PNG)",
+	)
+	flag.Parse()
+
 	inputContents, err := ioutil.ReadFile("../input.txt")
 	if err != nil {
 		panic(err)
@@ -235,14 +530,22 @@ func main() {
 		}
 	}
 
-	part1Res, err := part1(paths)
+	if *debugImagePath != "" {
+		if err := writeDebugImage(paths, *debugImagePath); err != nil {
+			panic(err)
+		}
+	}
+
+	intersections := intersectAll(paths)
+
+	part1Res, err := part1(paths, intersections)
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Println(part1Res)
 
-	part2Res, err := part2(paths)
+	part2Res, err := part2(paths, intersections)
 	if err != nil {
 		panic(err)
 	}
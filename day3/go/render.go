@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenderFormat selects the output format Render produces.
+type RenderFormat int
+
+const (
+	// RenderFormatPNG renders a raster image using image/png.
+	RenderFormatPNG RenderFormat = iota
+	// RenderFormatSVG renders vector markup as plain text, with no
+	// dependency on the raster image packages.
+	RenderFormatSVG
+)
+
+// RenderOptions configures how Render draws a set of wire paths.
+type RenderOptions struct {
+	// Format selects whether Render produces a PNG or an SVG.
+	Format RenderFormat
+	// Scale is how many output pixels/units each grid unit occupies.
+	Scale int
+	// Margin is how many pixels/units of blank space surround the drawn
+	// paths.
+	Margin int
+}
+
+// wireColors cycles through a small palette so that each wire in the
+// rendered image is visually distinct.
+var wireColors = []string{
+	"#1f77b4", // blue
+	"#2ca02c", // green
+	"#9467bd", // purple
+	"#ff7f0e", // orange
+	"#17becf", // teal
+}
+
+const (
+	originColor       = "#000000"
+	intersectionColor = "#d62728"
+	bestColor         = "#ffd700"
+)
+
+// Render draws paths, with intersections marked, to out in the format
+// described by opts. Each wire is drawn in a distinct color on a canvas
+// auto-scaled to fit every path, the origin is marked, and intersections are
+// highlighted, with the one closest to the origin called out distinctly.
+func Render(paths []Path, intersections []Point, out io.Writer, opts RenderOptions) error {
+	if opts.Scale <= 0 {
+		opts.Scale = 1
+	}
+
+	minPt, maxPt := boundingBoxOf(paths)
+	best, haveBest := closestToOrigin(intersections)
+
+	c := newCanvas(minPt, maxPt, opts)
+	for i, path := range paths {
+		col := wireColors[i%len(wireColors)]
+		for _, segment := range path.segments {
+			c.drawLine(segment.start, segment.end, col)
+		}
+	}
+
+	c.drawPoint(Point{0, 0}, originColor, 4)
+	for _, point := range intersections {
+		if point == (Point{0, 0}) {
+			continue
+		}
+
+		c.drawPoint(point, intersectionColor, 3)
+	}
+
+	if haveBest {
+		c.drawPoint(best, bestColor, 6)
+	}
+
+	if opts.Format == RenderFormatSVG {
+		return c.writeSVG(out)
+	}
+
+	return c.writePNG(out)
+}
+
+// closestToOrigin returns the non-origin point nearest to (0,0), mirroring
+// the "best" intersection part1 reports.
+func closestToOrigin(points []Point) (Point, bool) {
+	best := Point{}
+	haveBest := false
+	minDistance := int(^uint(0) >> 1)
+	for _, point := range points {
+		if point == (Point{0, 0}) {
+			continue
+		}
+
+		distance := point.DistanceTo(Point{0, 0})
+		if !haveBest || distance < minDistance {
+			best = point
+			minDistance = distance
+			haveBest = true
+		}
+	}
+
+	return best, haveBest
+}
+
+// boundingBoxOf returns the min and max corners spanning every point
+// visited by paths.
+func boundingBoxOf(paths []Path) (Point, Point) {
+	minPoint, maxPoint := Point{}, Point{}
+	for _, path := range paths {
+		for _, segment := range path.segments {
+			for _, p := range [2]Point{segment.start, segment.end} {
+				if p.x < minPoint.x {
+					minPoint.x = p.x
+				}
+				if p.y < minPoint.y {
+					minPoint.y = p.y
+				}
+				if p.x > maxPoint.x {
+					maxPoint.x = p.x
+				}
+				if p.y > maxPoint.y {
+					maxPoint.y = p.y
+				}
+			}
+		}
+	}
+
+	return minPoint, maxPoint
+}
+
+// canvas holds the pixel-space geometry shared by the PNG and SVG
+// renderers, plus whichever drawing backend the requested format uses.
+type canvas struct {
+	opts          RenderOptions
+	originX       int
+	originY       int
+	width, height int
+	img           *image.RGBA
+	svgElements   []string
+}
+
+// newCanvas builds a canvas sized to fit [minPt, maxPt] with opts.Margin of
+// padding on every side.
+func newCanvas(minPt, maxPt Point, opts RenderOptions) *canvas {
+	width := (maxPt.x-minPt.x)*opts.Scale + 2*opts.Margin + 1
+	height := (maxPt.y-minPt.y)*opts.Scale + 2*opts.Margin + 1
+
+	c := &canvas{
+		opts:    opts,
+		originX: -minPt.x*opts.Scale + opts.Margin,
+		// Grid y grows upward; image/SVG y grows downward, so flip it.
+		originY: maxPt.y*opts.Scale + opts.Margin,
+		width:   width,
+		height:  height,
+	}
+
+	if opts.Format != RenderFormatSVG {
+		c.img = image.NewRGBA(image.Rect(0, 0, width, height))
+		white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c.img.Set(x, y, white)
+			}
+		}
+	}
+
+	return c
+}
+
+// toPixel maps a grid point to its pixel/user-space coordinates.
+func (c *canvas) toPixel(p Point) (int, int) {
+	return c.originX + p.x*c.opts.Scale, c.originY - p.y*c.opts.Scale
+}
+
+// drawLine draws the segment from a to b, which must be axis-aligned.
+func (c *canvas) drawLine(a, b Point, col string) {
+	if c.opts.Format == RenderFormatSVG {
+		x1, y1 := c.toPixel(a)
+		x2, y2 := c.toPixel(b)
+		c.svgElements = append(c.svgElements, fmt.Sprintf(
+			`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"/>`,
+			x1, y1, x2, y2, col,
+		))
+		return
+	}
+
+	x1, y1 := c.toPixel(a)
+	x2, y2 := c.toPixel(b)
+	rgba := mustParseHexColor(col)
+	if x1 == x2 {
+		for y := min(y1, y2); y <= max(y1, y2); y++ {
+			c.img.Set(x1, y, rgba)
+		}
+		return
+	}
+
+	for x := min(x1, x2); x <= max(x1, x2); x++ {
+		c.img.Set(x, y1, rgba)
+	}
+}
+
+// drawPoint marks point with a filled circle of the given radius (in
+// pixels/units).
+func (c *canvas) drawPoint(p Point, col string, radius int) {
+	x, y := c.toPixel(p)
+
+	if c.opts.Format == RenderFormatSVG {
+		c.svgElements = append(c.svgElements, fmt.Sprintf(
+			`<circle cx="%d" cy="%d" r="%d" fill="%s"/>`, x, y, radius, col,
+		))
+		return
+	}
+
+	rgba := mustParseHexColor(col)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+
+			c.img.Set(x+dx, y+dy, rgba)
+		}
+	}
+}
+
+// writePNG encodes the rendered raster image to out.
+func (c *canvas) writePNG(out io.Writer) error {
+	return png.Encode(out, c.img)
+}
+
+// writeSVG writes the rendered vector markup to out.
+func (c *canvas) writeSVG(out io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", c.width, c.height)
+	fmt.Fprintf(&b, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	for _, el := range c.svgElements {
+		fmt.Fprintf(&b, "%s\n", el)
+	}
+	fmt.Fprint(&b, "</svg>\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// mustParseHexColor parses a "#rrggbb" string into a color.RGBA. It panics
+// on malformed input, which would indicate a bug in this file's own color
+// palette rather than bad user input.
+func mustParseHexColor(hex string) color.RGBA {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		panic(fmt.Sprintf("invalid color literal %q: %s", hex, err))
+	}
+
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+// writeDebugImage renders paths and their intersections to path, choosing a
+// raster or vector format based on its file extension (".svg" for SVG,
+// anything else for PNG).
+func writeDebugImage(paths []Path, path string) error {
+	format := RenderFormatPNG
+	if strings.EqualFold(filepath.Ext(path), ".svg") {
+		format = RenderFormatSVG
+	}
+
+	intersections := []Point{}
+	if len(paths) >= 2 {
+		intersections = intersectAll(paths)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create debug image file: %s", err)
+	}
+	defer f.Close()
+
+	return Render(paths, intersections, f, RenderOptions{Format: format, Scale: 4, Margin: 20})
+}
@@ -3,73 +3,211 @@ package main
 import (
 	"fmt"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 )
 
-func isValidPart1Password(password int) bool {
-	passwordStr := strconv.Itoa(password)
-	if len(passwordStr) != 6 {
-		return false
+// PasswordRule reports whether a password, given as its decimal digits
+// (most significant first), satisfies some constraint.
+type PasswordRule interface {
+	Allows(digits []int) bool
+}
+
+// RuleSet AND-composes a group of PasswordRules: a password is valid iff
+// every rule in the set allows it.
+type RuleSet []PasswordRule
+
+// Allows reports whether digits satisfies every rule in the set.
+func (rules RuleSet) Allows(digits []int) bool {
+	for _, rule := range rules {
+		if !rule.Allows(digits) {
+			return false
+		}
 	}
 
-	hasDoubleDigitPair := false
-	for i := range passwordStr[1:] {
-		// Convert each byte (in ASCII) to its numeric counterpart
-		digitA := int(passwordStr[i] - '0')
-		digitB := int(passwordStr[i+1] - '0')
-		if digitB < digitA {
+	return true
+}
+
+// Length requires a password to have exactly this many digits.
+type Length int
+
+// Allows implements PasswordRule.
+func (n Length) Allows(digits []int) bool {
+	return len(digits) == int(n)
+}
+
+// NonDecreasingDigits requires each digit to be greater than or equal to the
+// one before it.
+type NonDecreasingDigits struct{}
+
+// Allows implements PasswordRule.
+func (NonDecreasingDigits) Allows(digits []int) bool {
+	for i := 1; i < len(digits); i++ {
+		if digits[i] < digits[i-1] {
 			return false
-		} else if digitB == digitA {
-			hasDoubleDigitPair = true
 		}
 	}
 
-	return hasDoubleDigitPair
+	return true
 }
 
-// getAllPart1Passwords gets all valid passwords for part 1 in [lowerBound, upperBound]
-func getAllPart1Passwords(lowerBound, upperBound int) []int {
-	res := []int{}
-	for password := lowerBound; password <= upperBound; password++ {
-		if isValidPart1Password(password) {
-			res = append(res, password)
+// HasAdjacentRun requires at least one run of a repeated digit whose length
+// is between Min and Max, inclusive.
+type HasAdjacentRun struct {
+	Min int
+	Max int
+}
+
+// Allows implements PasswordRule.
+func (r HasAdjacentRun) Allows(digits []int) bool {
+	runLength := 1
+	for i := 1; i <= len(digits); i++ {
+		if i < len(digits) && digits[i] == digits[i-1] {
+			runLength++
+			continue
+		}
+
+		if runLength >= r.Min && runLength <= r.Max {
+			return true
 		}
+
+		runLength = 1
 	}
 
-	return res
+	return false
+}
+
+// DigitSetAllowed requires every digit in a password to be one of the given
+// values.
+type DigitSetAllowed []int
+
+// Allows implements PasswordRule.
+func (allowed DigitSetAllowed) Allows(digits []int) bool {
+	for _, d := range digits {
+		found := false
+		for _, a := range allowed {
+			if d == a {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// intToDigits splits n into its decimal digits, most significant first.
+func intToDigits(n int) []int {
+	s := strconv.Itoa(n)
+	digits := make([]int, len(s))
+	for i, c := range s {
+		digits[i] = int(c - '0')
+	}
+
+	return digits
+}
+
+// digitsToInt combines digits, most significant first, into an int.
+func digitsToInt(digits []int) int {
+	n := 0
+	for _, d := range digits {
+		n = n*10 + d
+	}
+
+	return n
 }
 
-func isValidPart2Password(password int) bool {
-	// The part 2 rules are stricter than part 1; they must be a valid part 1 password
-	if !isValidPart1Password(password) {
-		return false
-	}
-
-	lastChar := rune(0)
-	charCount := 0
-	passwordStr := strconv.Itoa(password)
-	// We will define an isolated pair to be a set like 22, but not 222
-	hasIsolatedRepeatedPair := false
-	for _, char := range passwordStr {
-		if lastChar == char {
-			charCount++
-		} else if hasIsolatedRepeatedPair {
-			// Once we find an isolated pair, the other digits don't matter
+// hasNonDecreasingDigits reports whether rules includes NonDecreasingDigits,
+// which is the only rule searchDigits is able to prune on directly.
+func hasNonDecreasingDigits(rules RuleSet) bool {
+	for _, rule := range rules {
+		if _, ok := rule.(NonDecreasingDigits); ok {
 			return true
-		} else {
-			charCount = 1
+		}
+	}
+
+	return false
+}
+
+// Search finds every positive integer in [lower, upper] that satisfies rules,
+// sharding the search by leading digit across runtime.NumCPU() goroutines.
+// Leading digits run from 1 to 9, so 0 and negative numbers are never found.
+// If rules includes NonDecreasingDigits, candidates are generated directly
+// in non-decreasing order, pruning whole subtrees that rule would reject;
+// otherwise every digit combination is generated.
+func Search(lower, upper int, rules RuleSet) []int {
+	results := make(chan int, 1024)
+	var wg sync.WaitGroup
+
+	minLength := len(strconv.Itoa(lower))
+	maxLength := len(strconv.Itoa(upper))
+	nonDecreasing := hasNonDecreasingDigits(rules)
+
+	leadingDigits := make(chan int, 9)
+	for d := 1; d <= 9; d++ {
+		leadingDigits <- d
+	}
+	close(leadingDigits)
+
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for first := range leadingDigits {
+				for length := minLength; length <= maxLength; length++ {
+					searchDigits([]int{first}, length, lower, upper, nonDecreasing, rules, results)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	res := []int{}
+	for n := range results {
+		res = append(res, n)
+	}
+
+	sort.Ints(res)
+	return res
+}
+
+// searchDigits extends prefix one digit at a time until it reaches length
+// digits, then reports the resulting number on results if it falls within
+// [lower, upper] and satisfies rules. When nonDecreasing is set, only
+// digits greater than or equal to the last one are tried, pruning subtrees
+// NonDecreasingDigits would reject anyway.
+func searchDigits(prefix []int, length, lower, upper int, nonDecreasing bool, rules RuleSet, results chan<- int) {
+	if len(prefix) == length {
+		n := digitsToInt(prefix)
+		if n < lower || n > upper {
+			return
 		}
 
-		lastChar = char
-		if charCount > 2 {
-			// If the char count ever exceeds two, we need to say that we don't have an isolated pair
-			hasIsolatedRepeatedPair = false
-		} else if charCount == 2 {
-			hasIsolatedRepeatedPair = true
+		if rules.Allows(prefix) {
+			results <- n
 		}
+
+		return
+	}
+
+	next := 0
+	if nonDecreasing {
+		next = prefix[len(prefix)-1]
 	}
 
-	return hasIsolatedRepeatedPair
+	for ; next <= 9; next++ {
+		searchDigits(append(append([]int{}, prefix...), next), length, lower, upper, nonDecreasing, rules, results)
+	}
 }
 
 func main() {
@@ -87,13 +225,22 @@ func main() {
 		panic(fmt.Sprintf("could not parse upper bound: %s", err))
 	}
 
-	part1Passwords := getAllPart1Passwords(lowerBound, upperBound)
+	part1Rules := RuleSet{
+		Length(len(strconv.Itoa(upperBound))),
+		NonDecreasingDigits{},
+		HasAdjacentRun{Min: 2, Max: 9},
+	}
+
+	part1Passwords := Search(lowerBound, upperBound, part1Rules)
 	fmt.Println(len(part1Passwords))
 
-	// We can save some computation by just going over our existing part 1 passwords for part 2
+	// The part 2 rules are stricter than part 1's, so we can save some
+	// computation by filtering the part 1 passwords instead of searching
+	// the whole range again.
+	part2Rule := HasAdjacentRun{Min: 2, Max: 2}
 	numPart2Passwords := 0
 	for _, password := range part1Passwords {
-		if isValidPart2Password(password) {
+		if part2Rule.Allows(intToDigits(password)) {
 			numPart2Passwords++
 		}
 	}